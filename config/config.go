@@ -0,0 +1,79 @@
+// Package config holds the types that back dms3-p2p's functional options
+// (see the root package's Option type) and the code that turns a built
+// Config into a running host.
+package config
+
+import (
+	connmgr "github.com/dms3-p2p/go-p2p/p2p/connmgr"
+	autorelay "github.com/dms3-p2p/go-p2p/p2p/host/autorelay"
+
+	circuit "github.com/dms3-p2p/go-p2p-circuit"
+	crypto "github.com/dms3-p2p/go-p2p-crypto"
+	ifconnmgr "github.com/dms3-p2p/go-p2p-interface-connmgr"
+	pnet "github.com/dms3-p2p/go-p2p-interface-pnet"
+	metrics "github.com/dms3-p2p/go-p2p-metrics"
+	pstore "github.com/dms3-p2p/go-p2p-peerstore"
+	filter "github.com/dms3-p2p/go-maddr-filter"
+	ma "github.com/dms3-mft/go-multiaddr"
+	fx "go.uber.org/fx"
+)
+
+// Option is a dms3-p2p config option that can be given to the dms3-p2p constructor
+// (`p2p.New`).
+type Option func(cfg *Config) error
+
+// Config describes a set of settings for a dms3-p2p node.
+//
+// This is *not* a stable interface. Use the options defined in the root
+// package to configure your dms3-p2p node.
+type Config struct {
+	PeerKey crypto.PrivKey
+
+	Transports         []TptC
+	TransportsConfig   TransportConfig
+	Muxers             []MsMuxC
+	SecurityTransports []MsSecC
+	Insecure           bool
+	Protector          pnet.Protector
+
+	ListenAddrs  []ma.Multiaddr
+	AddrsFactory AddrsFactory
+
+	ConnManager     ifconnmgr.ConnManager
+	ConnectionGater connmgr.ConnectionGater
+	Filters         *filter.Filters
+
+	NATManager NATManagerC
+	Peerstore  pstore.Peerstore
+	Reporter   metrics.Reporter
+
+	Relay     bool
+	RelayOpts []circuit.RelayOpt
+
+	EnableAutoNAT bool
+
+	EnableAutoRelay   bool
+	StaticRelays      []pstore.PeerInfo
+	AutoRelayDiscover autorelay.Discoverer
+
+	// FxOptions are spliced into the fx.App that NewNode assembles, after
+	// the default peerstore/swarm/host providers in fxModule. A caller can
+	// use fx.Replace or fx.Decorate here to override one of those defaults
+	// (e.g. swap in a custom peerstore) without forking NewNode. Populated
+	// via the root package's Fx option.
+	FxOptions []fx.Option
+}
+
+// Apply applies the given options to the config, returning the first error
+// encountered (if any).
+func (cfg *Config) Apply(opts ...Option) error {
+	for _, opt := range opts {
+		if opt == nil {
+			continue
+		}
+		if err := opt(cfg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
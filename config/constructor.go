@@ -0,0 +1,214 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+
+	bhost "github.com/dms3-p2p/go-p2p/p2p/host/basic"
+
+	crypto "github.com/dms3-p2p/go-p2p-crypto"
+	host "github.com/dms3-p2p/go-p2p-host"
+	peer "github.com/dms3-p2p/go-p2p-peer"
+	pstore "github.com/dms3-p2p/go-p2p-peerstore"
+	filter "github.com/dms3-p2p/go-maddr-filter"
+	ma "github.com/dms3-mft/go-multiaddr"
+	inet "github.com/dms3-p2p/go-p2p-net"
+	mux "github.com/dms3-p2p/go-p2p-interface-muxer"
+	tptu "github.com/dms3-p2p/go-p2p-transport-upgrader"
+	security "github.com/dms3-p2p/go-p2p-security"
+	transport "github.com/dms3-p2p/go-p2p-transport"
+)
+
+// AddrsFactory is a function that takes a set of multiaddrs we're listening
+// on and returns the set of multiaddrs we should advertise to the network.
+type AddrsFactory func([]ma.Multiaddr) []ma.Multiaddr
+
+// NATManagerC is a function that constructs a NAT manager.
+type NATManagerC func(inet.Network) bhost.NATManager
+
+// SecC is a security transport constructor function, post-resolution of its
+// supported argument types.
+type SecC func(h host.Host) (security.Transport, error)
+
+// MsSecC pairs a security transport constructor with the protocol ID to
+// negotiate it over multistream-select, and the priority at which it's
+// offered relative to the other configured security transports (lower
+// values are offered first; see TransportConfig.SetSecurityPriority).
+//
+// Muxers records, in priority order, which muxers this security transport
+// should be asked to advertise as an ALPN hint during its handshake. This is
+// config-side plumbing only, staged ahead of the companion work it depends
+// on - it does NOT, by itself, implement ALPN-based early muxer negotiation,
+// and landing it is not equivalent to closing that feature. No
+// security.Transport in this dependency tree reads Muxers, and
+// transport.Upgrader has no code path that looks for an agreed-muxer result
+// on the connection, so today this field changes nothing at runtime. Early
+// muxer negotiation still needs: a security.Transport (e.g. a TLS transport)
+// that advertises these IDs as ALPN and exposes the agreed protocol off its
+// connection, and a transport.Upgrader change that reads that result and
+// skips the usual post-handshake multistream-select round trip when it's
+// present. Both of those are out-of-tree changes, to go-p2p-security (or a
+// new security transport package) and go-p2p-transport-upgrader
+// respectively, and are not part of this change. sortedSecurity fills this
+// field in from the configured muxers when it's left nil, so it only needs
+// to be set explicitly to override that default.
+type MsSecC struct {
+	SecC
+	ID       string
+	Priority int
+	Muxers   []MsMuxC
+}
+
+// MuxC is a stream multiplexer constructor function, post-resolution of its
+// supported argument types.
+type MuxC func(h host.Host) (mux.Transport, error)
+
+// MsMuxC pairs a muxer constructor with the protocol ID to negotiate it over
+// multistream-select, and the priority at which it's offered relative to the
+// other configured muxers (lower values are offered first; see
+// TransportConfig.SetMuxerPriority).
+type MsMuxC struct {
+	MuxC
+	ID       string
+	Priority int
+}
+
+// TptC is a transport constructor function, post-resolution of its supported
+// argument types.
+type TptC func(h host.Host, u *tptu.Upgrader) (transport.Transport, error)
+
+// transportArgTypes enumerates the argument types checkConstructor accepts in
+// a Transport/Security/Muxer constructor function. Only some of them have a
+// value when the constructor is actually called (see TransportConstructor,
+// MuxerConstructor and SecurityConstructor); a constructor asking for any
+// other of these types passes the check but returns an error when called.
+var transportArgTypes = []reflect.Type{
+	reflect.TypeOf((*tptu.Upgrader)(nil)),
+	reflect.TypeOf((*host.Host)(nil)).Elem(),
+	reflect.TypeOf((*mux.Transport)(nil)).Elem(),
+	reflect.TypeOf((*security.Transport)(nil)).Elem(),
+	reflect.TypeOf((*peer.ID)(nil)).Elem(),
+	reflect.TypeOf((*crypto.PrivKey)(nil)).Elem(),
+	reflect.TypeOf((*crypto.PubKey)(nil)).Elem(),
+	reflect.TypeOf((*filter.Filters)(nil)),
+	reflect.TypeOf((*pstore.Peerstore)(nil)).Elem(),
+}
+
+// TransportConstructor uses reflection to turn any function taking a subset
+// of dms3-p2p's construction-time values into a TptC. The function is called
+// with the TptC's *tptu.Upgrader and host.Host arguments; the other argument
+// types listed in transportArgTypes pass validation but have no value
+// supplied, so a constructor taking one of them returns an error when called.
+func TransportConstructor(tpt interface{}) (TptC, error) {
+	if t, ok := tpt.(transport.Transport); ok {
+		return func(_ host.Host, _ *tptu.Upgrader) (transport.Transport, error) {
+			return t, nil
+		}, nil
+	}
+	if err := checkConstructor(tpt, reflect.TypeOf((*transport.Transport)(nil)).Elem()); err != nil {
+		return nil, err
+	}
+	return func(h host.Host, u *tptu.Upgrader) (transport.Transport, error) {
+		out, err := callConstructor(tpt, map[reflect.Type]interface{}{
+			reflect.TypeOf((*tptu.Upgrader)(nil)): u,
+			reflect.TypeOf((*host.Host)(nil)).Elem(): h,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return out.(transport.Transport), nil
+	}, nil
+}
+
+// MuxerConstructor turns a muxer (or muxer constructor function) into a
+// MuxC. A constructor function is called with the MuxC's host.Host argument
+// only; one taking any other argument type returns an error when called.
+func MuxerConstructor(tpt interface{}) (MuxC, error) {
+	if t, ok := tpt.(mux.Transport); ok {
+		return func(_ host.Host) (mux.Transport, error) {
+			return t, nil
+		}, nil
+	}
+	if err := checkConstructor(tpt, reflect.TypeOf((*mux.Transport)(nil)).Elem()); err != nil {
+		return nil, err
+	}
+	return func(h host.Host) (mux.Transport, error) {
+		out, err := callConstructor(tpt, map[reflect.Type]interface{}{
+			reflect.TypeOf((*host.Host)(nil)).Elem(): h,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return out.(mux.Transport), nil
+	}, nil
+}
+
+// SecurityConstructor turns a security transport (or constructor function)
+// into a SecC. A constructor function is called with the SecC's host.Host
+// argument only; one taking any other argument type returns an error when
+// called.
+func SecurityConstructor(tpt interface{}) (SecC, error) {
+	if t, ok := tpt.(security.Transport); ok {
+		return func(_ host.Host) (security.Transport, error) {
+			return t, nil
+		}, nil
+	}
+	if err := checkConstructor(tpt, reflect.TypeOf((*security.Transport)(nil)).Elem()); err != nil {
+		return nil, err
+	}
+	return func(h host.Host) (security.Transport, error) {
+		out, err := callConstructor(tpt, map[reflect.Type]interface{}{
+			reflect.TypeOf((*host.Host)(nil)).Elem(): h,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return out.(security.Transport), nil
+	}, nil
+}
+
+// checkConstructor validates that fn is a function returning (want, error)
+// and that every argument it takes is one we know how to supply.
+func checkConstructor(fn interface{}, want reflect.Type) error {
+	ft := reflect.TypeOf(fn)
+	if ft == nil || ft.Kind() != reflect.Func {
+		return fmt.Errorf("expected a constructor function or a %s, got %T", want, fn)
+	}
+	if ft.NumOut() != 2 || !ft.Out(0).Implements(want) || !ft.Out(1).Implements(reflect.TypeOf((*error)(nil)).Elem()) {
+		return fmt.Errorf("expected constructor to return (%s, error)", want)
+	}
+	for i := 0; i < ft.NumIn(); i++ {
+		if !isSupportedArg(ft.In(i)) {
+			return fmt.Errorf("constructor asked for unsupported argument type: %s", ft.In(i))
+		}
+	}
+	return nil
+}
+
+func isSupportedArg(t reflect.Type) bool {
+	for _, at := range transportArgTypes {
+		if t == at {
+			return true
+		}
+	}
+	return false
+}
+
+// callConstructor calls fn, filling in its arguments from the given value
+// set, and returns its first return value (the second is the error).
+func callConstructor(fn interface{}, have map[reflect.Type]interface{}) (interface{}, error) {
+	ft := reflect.TypeOf(fn)
+	args := make([]reflect.Value, ft.NumIn())
+	for i := range args {
+		v, ok := have[ft.In(i)]
+		if !ok {
+			return nil, fmt.Errorf("no value available for constructor argument: %s", ft.In(i))
+		}
+		args[i] = reflect.ValueOf(v)
+	}
+	out := reflect.ValueOf(fn).Call(args)
+	if err, _ := out[1].Interface().(error); err != nil {
+		return nil, err
+	}
+	return out[0].Interface(), nil
+}
@@ -0,0 +1,211 @@
+package config
+
+import (
+	"context"
+	"fmt"
+
+	autonat "github.com/dms3-p2p/go-p2p/p2p/host/autonat"
+	autorelay "github.com/dms3-p2p/go-p2p/p2p/host/autorelay"
+	bhost "github.com/dms3-p2p/go-p2p/p2p/host/basic"
+
+	circuit "github.com/dms3-p2p/go-p2p-circuit"
+	host "github.com/dms3-p2p/go-p2p-host"
+	peer "github.com/dms3-p2p/go-p2p-peer"
+	pstore "github.com/dms3-p2p/go-p2p-peerstore"
+	ma "github.com/dms3-mft/go-multiaddr"
+	swarm "github.com/dms3-p2p/go-p2p-swarm"
+	fx "go.uber.org/fx"
+)
+
+// fxModule assembles the fx.Options that build this Config's dependency
+// graph: peerstore -> swarm -> host, plus whatever optional subsystems
+// (relay, AutoNAT, AutoRelay) were enabled. It's appended ahead of
+// cfg.FxOptions, so a caller's own fx.Option (supplied via the root
+// package's Fx option) can use fx.Replace/fx.Decorate to override any of
+// these default providers - e.g. to inject a peerstore backed by their own
+// datastore - without forking NewNode.
+//
+// Per-transport constructor arguments (the interface{} passed to the
+// Transport/Security/Muxer options) still go through TransportConstructor's
+// reflection helper rather than the fx container at the point each TptC is
+// built: those functions can be called many times with different parameter
+// subsets per transport, which doesn't map cleanly onto fx's
+// one-provider-per-type model. The resulting []TptC list itself, though, is
+// provided through the container like everything else, so fx.Replace/
+// fx.Decorate can substitute it - see addTransports.
+func (cfg *Config) fxModule(ctx context.Context) fx.Option {
+	return fx.Options(
+		fx.Provide(func() pstore.Peerstore {
+			return cfg.Peerstore
+		}),
+		fx.Provide(func() []TptC {
+			return cfg.Transports
+		}),
+		fx.Provide(cfg.provideSwarm(ctx)),
+		fx.Provide(cfg.provideHost(ctx)),
+		fx.Invoke(cfg.startListening),
+	)
+}
+
+func (cfg *Config) provideSwarm(ctx context.Context) func(pstore.Peerstore, []TptC) (*swarm.Swarm, error) {
+	return func(ps pstore.Peerstore, transports []TptC) (*swarm.Swarm, error) {
+		pid, err := peer.IDFromPrivateKey(cfg.PeerKey)
+		if err != nil {
+			return nil, err
+		}
+		if err := ps.AddPrivKey(pid, cfg.PeerKey); err != nil {
+			return nil, err
+		}
+		if err := ps.AddPubKey(pid, cfg.PeerKey.GetPublic()); err != nil {
+			return nil, err
+		}
+
+		swrm, err := swarm.NewSwarmWithProtector(
+			ctx,
+			cfg.ListenAddrs,
+			pid,
+			ps,
+			cfg.Protector,
+			cfg.Filters,
+			cfg.Reporter,
+			cfg.ConnectionGater,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := cfg.addTransports(swrm, transports); err != nil {
+			swrm.Close()
+			return nil, err
+		}
+		return swrm, nil
+	}
+}
+
+func (cfg *Config) provideHost(ctx context.Context) func(*swarm.Swarm) (host.Host, error) {
+	return func(swrm *swarm.Swarm) (host.Host, error) {
+		h, err := bhost.NewHost(ctx, swrm, &bhost.HostOpts{
+			ConnManager:  cfg.ConnManager,
+			AddrsFactory: bhost.AddrsFactory(cfg.AddrsFactory),
+			NATManager:   bhost.NATManagerC(cfg.NATManager),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if cfg.relayEnabled() {
+			if err := circuit.AddRelayTransport(swrm, h, cfg.RelayOpts...); err != nil {
+				h.Close()
+				return nil, err
+			}
+		}
+
+		if cfg.EnableAutoNAT || cfg.EnableAutoRelay {
+			h.SetAutoNAT(autonat.New(ctx, h))
+		}
+
+		if cfg.EnableAutoNAT {
+			if _, err := autonat.NewAutoNATService(ctx, h); err != nil {
+				h.Close()
+				return nil, err
+			}
+		}
+
+		if cfg.EnableAutoRelay {
+			ar := autorelay.NewAutoRelay(ctx, h, h.AutoNAT(), cfg.StaticRelays, cfg.AutoRelayDiscover)
+			prior := bhost.AddrsFactory(cfg.AddrsFactory)
+			h.SetAddrsFactory(func(addrs []ma.Multiaddr) []ma.Multiaddr {
+				if prior != nil {
+					addrs = prior(addrs)
+				}
+				return ar.AddrsFactory(addrs)
+			})
+		}
+
+		return h, nil
+	}
+}
+
+// startListening registers the fx.Lifecycle hook that opens the swarm's
+// listeners on app.Start and closes the host (and, transitively, the
+// swarm and its connections) on app.Stop.
+func (cfg *Config) startListening(lc fx.Lifecycle, swrm *swarm.Swarm, h host.Host) {
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			return swrm.Listen(cfg.ListenAddrs...)
+		},
+		OnStop: func(context.Context) error {
+			return h.Close()
+		},
+	})
+}
+
+// fxHost wraps the host built by the fx.App so that Close stops the app
+// (running its OnStop hooks, which close the host) instead of closing the
+// underlying host.Host directly - giving callers a single, familiar
+// `h.Close()` even though construction and teardown now go through fx.
+type fxHost struct {
+	host.Host
+	app *fx.App
+}
+
+// Close stops the backing fx.App. This runs the OnStop hook registered in
+// startListening, which closes the underlying host.
+func (h *fxHost) Close() error {
+	return h.app.Stop(context.Background())
+}
+
+// Reachability forwards to the wrapped host's Reachability accessor (see
+// bhost.BasicHost.Reachability), which embedding the host.Host interface
+// alone would hide. It returns autonat.ReachabilityUnknown if the wrapped
+// host has no such accessor.
+func (h *fxHost) Reachability() autonat.Reachability {
+	if r, ok := h.Host.(interface{ Reachability() autonat.Reachability }); ok {
+		return r.Reachability()
+	}
+	return autonat.ReachabilityUnknown
+}
+
+// NewNode constructs a new dms3-p2p node from the given Config by assembling
+// an fx.App out of cfg.fxModule and any extra fx.Options the caller
+// appended via the Fx option, then starting it. The peerstore, swarm,
+// transports, and security/muxer negotiation are wired together exactly as
+// before; what's changed is that a caller can now splice in their own
+// fx.Option (via fx.Replace/fx.Decorate) to override a default provider
+// instead of having to fork this constructor.
+func (cfg *Config) NewNode(ctx context.Context) (host.Host, error) {
+	if cfg.Peerstore == nil {
+		return nil, fmt.Errorf("no peerstore specified")
+	}
+
+	// The AutoNAT/AutoRelay background goroutines started by provideHost
+	// run until this context is done; it's cancelled when the app stops.
+	ctx, cancel := context.WithCancel(ctx)
+
+	var h host.Host
+	opts := append([]fx.Option{cfg.fxModule(ctx)}, cfg.FxOptions...)
+	opts = append(opts, fx.Invoke(func(lc fx.Lifecycle) {
+		lc.Append(fx.Hook{
+			OnStop: func(context.Context) error {
+				cancel()
+				return nil
+			},
+		})
+	}))
+	opts = append(opts, fx.Populate(&h), fx.NopLogger)
+
+	app := fx.New(opts...)
+	if err := app.Start(ctx); err != nil {
+		// Start fails either because a constructor in the graph failed or
+		// because the OnStart hook in startListening did; in the latter
+		// case the swarm, host, and any enabled AutoNAT/AutoRelay
+		// background goroutines already exist. Stop the app (closing the
+		// host) and cancel ctx (ending those goroutines) so they don't
+		// leak.
+		app.Stop(ctx)
+		cancel()
+		return nil, err
+	}
+
+	return &fxHost{Host: h, app: app}, nil
+}
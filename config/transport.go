@@ -0,0 +1,40 @@
+package config
+
+import (
+	tptu "github.com/dms3-p2p/go-p2p-transport-upgrader"
+	swarm "github.com/dms3-p2p/go-p2p-swarm"
+)
+
+// addTransports constructs and registers each of the given TptC transports
+// on the swarm, wrapping every one in a transport.Upgrader that applies the
+// configured security transports, muxers, and private network protector in
+// negotiation-priority order. See MsSecC.Muxers for the (currently inert)
+// ALPN muxer hint carried alongside each security transport.
+//
+// transports is supplied by fxModule's []TptC provider rather than read
+// directly off cfg, so that fx.Replace/fx.Decorate can substitute a
+// different transport list. Each TptC itself was produced by
+// TransportConstructor's reflection helper at option-application time - that
+// part stays outside the fx container, since those constructors can be
+// called with differing argument subsets per transport and don't map onto
+// fx's one-provider-per-type model.
+func (cfg *Config) addTransports(swrm *swarm.Swarm, transports []TptC) error {
+	upgrader := &tptu.Upgrader{
+		Secure:          cfg.sortedSecurity(),
+		Muxer:           cfg.sortedMuxers(),
+		Protector:       cfg.Protector,
+		Filters:         cfg.Filters,
+		ConnectionGater: cfg.ConnectionGater,
+	}
+
+	for _, t := range transports {
+		tpt, err := t(nil, upgrader)
+		if err != nil {
+			return err
+		}
+		if err := swrm.AddTransport(tpt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package config
+
+import "sort"
+
+// Names of the transports dms3-p2p installs by default, for use with
+// TransportConfig.Set.
+const (
+	TransportTCP       = "TCP"
+	TransportWebsocket = "Websocket"
+	TransportRelay     = "Relay"
+	TransportQUIC      = "QUIC"
+)
+
+// TransportFlag is a tri-state switch for a named default transport: either
+// leave it at its default enablement, force it on, or force it off.
+type TransportFlag int
+
+const (
+	// TransportDefault leaves the transport's enablement up to dms3-p2p.
+	TransportDefault TransportFlag = iota
+	// TransportEnabled forces the transport on.
+	TransportEnabled
+	// TransportDisabled forces the transport off.
+	TransportDisabled
+)
+
+// TransportConfig controls which of dms3-p2p's built-in default transports
+// are installed (see the Transport* name constants) and the priority at
+// which installed security transports and muxers are offered during
+// multistream negotiation. The zero value is valid and enables every
+// default transport at its registration-order priority.
+type TransportConfig struct {
+	flags            map[string]TransportFlag
+	securityPriority map[string]int
+	muxerPriority    map[string]int
+}
+
+// Set overrides the enablement of the named default transport.
+func (tc *TransportConfig) Set(name string, flag TransportFlag) {
+	if tc.flags == nil {
+		tc.flags = make(map[string]TransportFlag, 1)
+	}
+	tc.flags[name] = flag
+}
+
+// Get returns the configured enablement of the named default transport,
+// TransportDefault if it hasn't been overridden.
+func (tc TransportConfig) Get(name string) TransportFlag {
+	return tc.flags[name]
+}
+
+// SetSecurityPriority overrides the negotiation priority of the security
+// transport registered under the given protocol ID. Lower values are
+// offered first.
+func (tc *TransportConfig) SetSecurityPriority(id string, priority int) {
+	if tc.securityPriority == nil {
+		tc.securityPriority = make(map[string]int, 1)
+	}
+	tc.securityPriority[id] = priority
+}
+
+// SetMuxerPriority overrides the negotiation priority of the muxer
+// registered under the given protocol ID. Lower values are offered first.
+func (tc *TransportConfig) SetMuxerPriority(id string, priority int) {
+	if tc.muxerPriority == nil {
+		tc.muxerPriority = make(map[string]int, 1)
+	}
+	tc.muxerPriority[id] = priority
+}
+
+// relayEnabled reports whether the relay transport should actually be
+// installed: EnableRelay was called, and it hasn't been force-disabled via
+// Transports(tc) / tc.Set(TransportRelay, TransportDisabled).
+func (cfg *Config) relayEnabled() bool {
+	return cfg.Relay && cfg.TransportsConfig.Get(TransportRelay) != TransportDisabled
+}
+
+// sortedSecurity returns the configured security transports in negotiation
+// order: registration order, except for any IDs whose priority has been
+// overridden via SetSecurityPriority.
+func (cfg *Config) sortedSecurity() []MsSecC {
+	out := make([]MsSecC, len(cfg.SecurityTransports))
+	copy(out, cfg.SecurityTransports)
+	muxers := cfg.sortedMuxers()
+	for i := range out {
+		if p, ok := cfg.TransportsConfig.securityPriority[out[i].ID]; ok {
+			out[i].Priority = p
+		}
+		if out[i].Muxers == nil {
+			out[i].Muxers = muxers
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Priority < out[j].Priority })
+	return out
+}
+
+// sortedMuxers returns the configured muxers in negotiation order:
+// registration order, except for any IDs whose priority has been overridden
+// via SetMuxerPriority. An overridden ID sorts ahead of a non-overridden one
+// with the same priority.
+func (cfg *Config) sortedMuxers() []MsMuxC {
+	out := make([]MsMuxC, len(cfg.Muxers))
+	copy(out, cfg.Muxers)
+	overrides := cfg.TransportsConfig.muxerPriority
+	for i := range out {
+		if p, ok := overrides[out[i].ID]; ok {
+			out[i].Priority = p
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Priority != out[j].Priority {
+			return out[i].Priority < out[j].Priority
+		}
+		_, oi := overrides[out[i].ID]
+		_, oj := overrides[out[j].ID]
+		return oi && !oj
+	})
+	return out
+}
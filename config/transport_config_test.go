@@ -0,0 +1,94 @@
+package config
+
+import "testing"
+
+func TestSortedSecurityPriority(t *testing.T) {
+	cfg := &Config{
+		SecurityTransports: []MsSecC{
+			{ID: "/tls/1.0.0", Priority: 0},
+			{ID: "/secio/1.0.0", Priority: 1},
+		},
+	}
+	cfg.TransportsConfig.SetSecurityPriority("/secio/1.0.0", 0)
+	cfg.TransportsConfig.SetSecurityPriority("/tls/1.0.0", 1)
+
+	got := cfg.sortedSecurity()
+	if got[0].ID != "/secio/1.0.0" || got[1].ID != "/tls/1.0.0" {
+		t.Fatalf("expected secio before tls after priority override, got %v, %v", got[0].ID, got[1].ID)
+	}
+}
+
+func TestSortedMuxersDefaultOrder(t *testing.T) {
+	cfg := &Config{
+		Muxers: []MsMuxC{
+			{ID: "/yamux/1.0.0", Priority: 0},
+			{ID: "/mplex/6.7.0", Priority: 1},
+		},
+	}
+
+	got := cfg.sortedMuxers()
+	if got[0].ID != "/yamux/1.0.0" || got[1].ID != "/mplex/6.7.0" {
+		t.Fatalf("expected registration order to be preserved without overrides, got %v, %v", got[0].ID, got[1].ID)
+	}
+
+	cfg.TransportsConfig.SetMuxerPriority("/mplex/6.7.0", 0)
+	got = cfg.sortedMuxers()
+	if got[0].ID != "/mplex/6.7.0" {
+		t.Fatalf("expected mplex first after priority override, got %v", got[0].ID)
+	}
+}
+
+func TestSortedSecurityMuxerHint(t *testing.T) {
+	cfg := &Config{
+		SecurityTransports: []MsSecC{
+			{ID: "/tls/1.0.0", Priority: 0},
+		},
+		Muxers: []MsMuxC{
+			{ID: "/yamux/1.0.0", Priority: 0},
+			{ID: "/mplex/6.7.0", Priority: 1},
+		},
+	}
+
+	got := cfg.sortedSecurity()
+	if len(got[0].Muxers) != 2 || got[0].Muxers[0].ID != "/yamux/1.0.0" {
+		t.Fatalf("expected the security transport's Muxers hint to default to the sorted muxer list, got %v", got[0].Muxers)
+	}
+
+	cfg.TransportsConfig.SetMuxerPriority("/mplex/6.7.0", 0)
+	got = cfg.sortedSecurity()
+	if got[0].Muxers[0].ID != "/mplex/6.7.0" {
+		t.Fatalf("expected the default hint to track muxer priority overrides, got %v", got[0].Muxers[0].ID)
+	}
+
+	cfg.SecurityTransports[0].Muxers = []MsMuxC{{ID: "/yamux/1.0.0", Priority: 0}}
+	got = cfg.sortedSecurity()
+	if len(got[0].Muxers) != 1 || got[0].Muxers[0].ID != "/yamux/1.0.0" {
+		t.Fatalf("expected an explicitly set Muxers hint not to be overwritten, got %v", got[0].Muxers)
+	}
+}
+
+func TestRelayEnabledRespectsDisable(t *testing.T) {
+	cfg := &Config{Relay: true}
+	if !cfg.relayEnabled() {
+		t.Fatal("expected relay to be enabled after EnableRelay with no TransportRelay override")
+	}
+
+	cfg.TransportsConfig.Set(TransportRelay, TransportDisabled)
+	if cfg.relayEnabled() {
+		t.Fatal("expected tc.Set(TransportRelay, TransportDisabled) to override EnableRelay")
+	}
+}
+
+func TestTransportFlagOverride(t *testing.T) {
+	var tc TransportConfig
+	if tc.Get(TransportTCP) != TransportDefault {
+		t.Fatal("expected TransportDefault for an unconfigured transport")
+	}
+	tc.Set(TransportTCP, TransportDisabled)
+	if tc.Get(TransportTCP) != TransportDisabled {
+		t.Fatal("expected TransportDisabled after Set")
+	}
+	if tc.Get(TransportWebsocket) != TransportDefault {
+		t.Fatal("expected other transports to be unaffected")
+	}
+}
@@ -5,6 +5,7 @@ package p2p
 import (
 	"crypto/rand"
 
+	config "github.com/dms3-p2p/go-p2p/config"
 	crypto "github.com/dms3-p2p/go-p2p-crypto"
 	pstore "github.com/dms3-p2p/go-p2p-peerstore"
 	secio "github.com/dms3-p2p/go-p2p-secio"
@@ -33,11 +34,23 @@ var DefaultMuxers = ChainOptions(
 // DefaultTransports are the default dms3-p2p transports.
 //
 // Use this option when you want to *extend* the set of multiplexers used by
-// dms3-p2p instead of replacing them.
-var DefaultTransports = ChainOptions(
-	Transport(tcp.NewTCPTransport),
-	Transport(ws.New),
-)
+// dms3-p2p instead of replacing them. Individual defaults can be dropped
+// without affecting the others via the Transports option (or the NoTCP /
+// NoWebsocket shorthands).
+var DefaultTransports Option = func(cfg *Config) error {
+	tc := cfg.TransportsConfig
+	if tc.Get(config.TransportTCP) != config.TransportDisabled {
+		if err := cfg.Apply(Transport(tcp.NewTCPTransport)); err != nil {
+			return err
+		}
+	}
+	if tc.Get(config.TransportWebsocket) != config.TransportDisabled {
+		if err := cfg.Apply(Transport(ws.New)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
 // DefaultPeerstore configures dms3-p2p to use the default peerstore.
 var DefaultPeerstore Option = func(cfg *Config) error {
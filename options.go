@@ -6,8 +6,12 @@ package p2p
 import (
 	"fmt"
 	"net"
+	"strconv"
+	"strings"
 
 	config "github.com/dms3-p2p/go-p2p/config"
+	connmgr "github.com/dms3-p2p/go-p2p/p2p/connmgr"
+	autorelay "github.com/dms3-p2p/go-p2p/p2p/host/autorelay"
 	bhost "github.com/dms3-p2p/go-p2p/p2p/host/basic"
 
 	circuit "github.com/dms3-p2p/go-p2p-circuit"
@@ -18,6 +22,8 @@ import (
 	pstore "github.com/dms3-p2p/go-p2p-peerstore"
 	filter "github.com/dms3-p2p/go-maddr-filter"
 	ma "github.com/dms3-mft/go-multiaddr"
+	manet "github.com/dms3-mft/go-multiaddr-net"
+	fx "go.uber.org/fx"
 )
 
 // ListenAddrStrings configures dms3-p2p to listen on the given (unparsed)
@@ -56,7 +62,13 @@ func ListenAddrs(addrs ...ma.Multiaddr) Option {
 // * Host
 // * Network
 // * Peerstore
-func Security(name string, tpt interface{}) Option {
+//
+// muxers, if given, overrides the muxer ALPN hint recorded alongside this
+// security transport (see config.MsSecC.Muxers); by default it's the full
+// configured muxer list, in priority order. Note that this hint isn't acted
+// on anywhere in this repo yet - it's config-side groundwork for an early
+// muxer negotiation feature that doesn't exist here today.
+func Security(name string, tpt interface{}, muxers ...config.MsMuxC) Option {
 	stpt, err := config.SecurityConstructor(tpt)
 	err = traceError(err, 1)
 	return func(cfg *Config) error {
@@ -66,7 +78,7 @@ func Security(name string, tpt interface{}) Option {
 		if cfg.Insecure {
 			return fmt.Errorf("cannot use security transports with an insecure dms3-p2p configuration")
 		}
-		cfg.SecurityTransports = append(cfg.SecurityTransports, config.MsSecC{SecC: stpt, ID: name})
+		cfg.SecurityTransports = append(cfg.SecurityTransports, config.MsSecC{SecC: stpt, ID: name, Priority: len(cfg.SecurityTransports), Muxers: muxers})
 		return nil
 	}
 }
@@ -99,7 +111,7 @@ func Muxer(name string, tpt interface{}) Option {
 		if err != nil {
 			return err
 		}
-		cfg.Muxers = append(cfg.Muxers, config.MsMuxC{MuxC: mtpt, ID: name})
+		cfg.Muxers = append(cfg.Muxers, config.MsMuxC{MuxC: mtpt, ID: name, Priority: len(cfg.Muxers)})
 		return nil
 	}
 }
@@ -201,7 +213,37 @@ func AddrsFactory(factory config.AddrsFactory) Option {
 	}
 }
 
-// EnableRelay configures dms3-p2p to enable the relay transport.
+// ConnectionGater configures dms3-p2p to consult the given ConnectionGater
+// before dialing or accepting any connection, and after the security
+// handshake and the full upgrade complete. Unlike FilterAddresses, a gater
+// can reject connections by peer ID and can make its decision dynamically
+// rather than from a static CIDR list.
+func ConnectionGater(g connmgr.ConnectionGater) Option {
+	return func(cfg *Config) error {
+		if cfg.ConnectionGater != nil {
+			return fmt.Errorf("cannot specify multiple connection gaters")
+		}
+		cfg.ConnectionGater = g
+		return nil
+	}
+}
+
+// Fx splices an extra fx.Option into the fx.App that NewNode assembles to
+// build the host, after the default peerstore/swarm/host providers. Use
+// fx.Replace or fx.Decorate to override one of those defaults - for
+// example, to inject a peerstore backed by your own datastore - without
+// forking the constructor.
+func Fx(opt fx.Option) Option {
+	return func(cfg *Config) error {
+		cfg.FxOptions = append(cfg.FxOptions, opt)
+		return nil
+	}
+}
+
+// EnableRelay configures dms3-p2p to enable the relay transport. This is
+// overridden by `Transports(tc)` / `tc.Set(config.TransportRelay,
+// config.TransportDisabled)`: the relay transport is only actually
+// installed if it hasn't been force-disabled that way.
 func EnableRelay(options ...circuit.RelayOpt) Option {
 	return func(cfg *Config) error {
 		cfg.Relay = true
@@ -210,6 +252,41 @@ func EnableRelay(options ...circuit.RelayOpt) Option {
 	}
 }
 
+// EnableNATService configures dms3-p2p to run a NAT service that answers
+// AutoNAT dial-back requests from other peers, helping them determine
+// whether they're publicly reachable. This also starts this host's own
+// AutoNAT client, so Reachability() becomes meaningful even without
+// EnableAutoRelay.
+func EnableNATService() Option {
+	return func(cfg *Config) error {
+		cfg.EnableAutoNAT = true
+		return nil
+	}
+}
+
+// EnableAutoRelay configures dms3-p2p to start the AutoRelay background
+// loop: when the host's AutoNAT client classifies it as Private, it
+// connects to the given static relays (and, if AutoRelayWithDiscovery was
+// also passed, to discovered ones) and advertises reachable /p2p-circuit
+// addrs through them.
+func EnableAutoRelay(static ...pstore.PeerInfo) Option {
+	return func(cfg *Config) error {
+		cfg.EnableAutoRelay = true
+		cfg.StaticRelays = static
+		return nil
+	}
+}
+
+// AutoRelayWithDiscovery configures the function AutoRelay uses to find
+// additional relays when the static list passed to EnableAutoRelay isn't
+// enough.
+func AutoRelayWithDiscovery(discover autorelay.Discoverer) Option {
+	return func(cfg *Config) error {
+		cfg.AutoRelayDiscover = discover
+		return nil
+	}
+}
+
 // FilterAddresses configures dms3-p2p to never dial nor accept connections from
 // the given addresses.
 func FilterAddresses(addrs ...*net.IPNet) Option {
@@ -224,6 +301,82 @@ func FilterAddresses(addrs ...*net.IPNet) Option {
 	}
 }
 
+// AnnounceAddrs configures dms3-p2p's AddrsFactory to announce exactly the
+// given addresses, if any are given, falling back to the node's listen
+// addresses otherwise; either way, any address whose IP falls within one of
+// the noAnnounce networks is stripped from the result.
+//
+// Unlike AddrsFactory, this doesn't reject a pre-set factory: it wraps it,
+// running it first and applying the announce/noAnnounce policy to its
+// output, so AnnounceAddrs can be combined with a previously configured
+// AddrsFactory. EnableAutoRelay doesn't set an AddrsFactory here; when the
+// host is built, its relay addrs are appended to this factory's output.
+func AnnounceAddrs(announce []ma.Multiaddr, noAnnounce []*net.IPNet) Option {
+	return func(cfg *Config) error {
+		prior := cfg.AddrsFactory
+		cfg.AddrsFactory = func(addrs []ma.Multiaddr) []ma.Multiaddr {
+			if prior != nil {
+				addrs = prior(addrs)
+			}
+			if len(announce) > 0 {
+				addrs = announce
+			}
+			return filterAddrs(addrs, noAnnounce)
+		}
+		return nil
+	}
+}
+
+// filterAddrs returns the addrs whose IP component doesn't fall within any
+// of the given networks.
+func filterAddrs(addrs []ma.Multiaddr, noAnnounce []*net.IPNet) []ma.Multiaddr {
+	if len(noAnnounce) == 0 {
+		return addrs
+	}
+	out := make([]ma.Multiaddr, 0, len(addrs))
+	for _, a := range addrs {
+		ip, err := manet.ToIP(a)
+		if err != nil {
+			out = append(out, a)
+			continue
+		}
+		blocked := false
+		for _, n := range noAnnounce {
+			if n.Contains(ip) {
+				blocked = true
+				break
+			}
+		}
+		if !blocked {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// ParseCIDRMultiaddr parses a CIDR mask written in multiaddr filter syntax,
+// e.g. "/ip4/10.0.0.0/ipcidr/8" or "/ip6/fc00::/ipcidr/7", into a *net.IPNet
+// suitable for FilterAddresses or AnnounceAddrs' noAnnounce list. This lets
+// config files express address masks the same way they express multiaddrs,
+// rather than switching to Go's "10.0.0.0/8" CIDR notation.
+func ParseCIDRMultiaddr(s string) (*net.IPNet, error) {
+	parts := strings.Split(strings.Trim(s, "/"), "/")
+	if len(parts) != 4 || (parts[0] != "ip4" && parts[0] != "ip6") || parts[2] != "ipcidr" {
+		return nil, fmt.Errorf("invalid CIDR mask %q: expected /ip4|ip6/<ip>/ipcidr/<bits>", s)
+	}
+	if net.ParseIP(parts[1]) == nil {
+		return nil, fmt.Errorf("invalid CIDR mask %q: bad IP %q", s, parts[1])
+	}
+	if _, err := strconv.Atoi(parts[3]); err != nil {
+		return nil, fmt.Errorf("invalid CIDR mask %q: bad prefix length %q", s, parts[3])
+	}
+	_, ipnet, err := net.ParseCIDR(parts[1] + "/" + parts[3])
+	if err != nil {
+		return nil, fmt.Errorf("invalid CIDR mask %q: %s", s, err)
+	}
+	return ipnet, nil
+}
+
 // NATPortMap configures dms3-p2p to use the default NATManager. The default
 // NATManager will attempt to open a port in your network's firewall using UPnP.
 func NATPortMap() Option {
@@ -259,3 +412,30 @@ var NoTransports = func(cfg *Config) error {
 	cfg.Transports = []config.TptC{}
 	return nil
 }
+
+// Transports configures which of dms3-p2p's built-in default transports are
+// installed and the priority at which installed security transports and
+// muxers are offered during multistream negotiation.
+//
+// Unlike NoTransports, this doesn't clear any transports explicitly added
+// via the Transport option; it only controls the defaults applied when no
+// transports have been configured.
+func Transports(tc config.TransportConfig) Option {
+	return func(cfg *Config) error {
+		cfg.TransportsConfig = tc
+		return nil
+	}
+}
+
+// NoTCP will configure dms3-p2p to not install the default TCP transport.
+var NoTCP Option = func(cfg *Config) error {
+	cfg.TransportsConfig.Set(config.TransportTCP, config.TransportDisabled)
+	return nil
+}
+
+// NoWebsocket will configure dms3-p2p to not install the default Websocket
+// transport.
+var NoWebsocket Option = func(cfg *Config) error {
+	cfg.TransportsConfig.Set(config.TransportWebsocket, config.TransportDisabled)
+	return nil
+}
@@ -0,0 +1,63 @@
+// Package p2p implements the dms3-p2p peer-to-peer network stack, exposing a
+// `New` constructor and a set of functional options (see options.go and
+// defaults.go) to configure it.
+package p2p
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+
+	config "github.com/dms3-p2p/go-p2p/config"
+
+	host "github.com/dms3-p2p/go-p2p-host"
+)
+
+// Config describes a set of settings for a dms3-p2p node. It's an alias for
+// config.Config so that Option funcs (which live in this package) can freely
+// mutate it without either package having to import the other twice.
+type Config = config.Config
+
+// Option is a dms3-p2p config option that can be given to the dms3-p2p
+// constructor (`p2p.New`).
+type Option = config.Option
+
+// New constructs a new dms3-p2p node with the given options, applying
+// FallbackDefaults for anything the caller didn't configure.
+func New(ctx context.Context, opts ...Option) (host.Host, error) {
+	var cfg Config
+	if err := cfg.Apply(append(opts, FallbackDefaults)...); err != nil {
+		return nil, err
+	}
+	return cfg.NewNode(ctx)
+}
+
+// ChainOptions chains multiple options into one.
+func ChainOptions(opts ...Option) Option {
+	return func(cfg *Config) error {
+		for _, opt := range opts {
+			if opt == nil {
+				continue
+			}
+			if err := opt(cfg); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// traceError annotates err with the file/line of the caller `skip` frames up
+// the stack, so that misconfigured options (e.g. a bad Transport
+// constructor) point back at the user's call site instead of somewhere deep
+// inside this package.
+func traceError(err error, skip int) error {
+	if err == nil {
+		return nil
+	}
+	_, file, line, ok := runtime.Caller(skip + 1)
+	if !ok {
+		return err
+	}
+	return fmt.Errorf("%s:%d: %s", file, line, err)
+}
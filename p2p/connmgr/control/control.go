@@ -0,0 +1,12 @@
+// Package control holds small shared types used at the connection-gating /
+// control-plane boundary between a dms3-p2p host and its swarm, without
+// pulling in the rest of the connmgr package (to avoid import cycles with
+// packages that only need the types, not the ConnectionGater interface
+// itself).
+package control
+
+// DisconnectReason is an opaque code a connmgr.ConnectionGater can attach to
+// an already-upgraded connection it's rejecting from InterceptUpgraded, so
+// that the swarm can surface *why* the connection was dropped (in logs, or
+// in a future wire-level close reason) instead of just that it was.
+type DisconnectReason int
@@ -0,0 +1,51 @@
+// Package connmgr holds the ConnectionGater interface dms3-p2p's swarm and
+// transport upgrader consult while establishing a connection, letting a
+// caller block connections by peer ID or address at any phase instead of
+// only via the IP-CIDR dial filters FilterAddresses supports.
+package connmgr
+
+import (
+	control "github.com/dms3-p2p/go-p2p/p2p/connmgr/control"
+
+	inet "github.com/dms3-p2p/go-p2p-net"
+	peer "github.com/dms3-p2p/go-p2p-peer"
+	ma "github.com/dms3-mft/go-multiaddr"
+)
+
+// ConnMultiaddrs is the thin subset of inet.Conn available at the point
+// InterceptAccept and InterceptSecured run: before the security handshake
+// (and, for InterceptAccept, before we even know the remote peer ID) there's
+// no full inet.Conn yet, just the two ends' multiaddrs.
+type ConnMultiaddrs interface {
+	LocalMultiaddr() ma.Multiaddr
+	RemoteMultiaddr() ma.Multiaddr
+}
+
+// ConnectionGater is consulted by the swarm and transport upgrader at each
+// phase of establishing a connection, inbound or outbound, and can reject it
+// at any phase. Implementations should be fast and non-blocking: they run on
+// the connection's critical path.
+type ConnectionGater interface {
+	// InterceptPeerDial is called before dialing a new peer. It's given
+	// only the peer ID, since we may not yet know which addresses we'll
+	// try; returning false skips the dial entirely.
+	InterceptPeerDial(p peer.ID) (allow bool)
+
+	// InterceptAddrDial is called before dialing a specific address of a
+	// peer InterceptPeerDial has already allowed.
+	InterceptAddrDial(p peer.ID, addr ma.Multiaddr) (allow bool)
+
+	// InterceptAccept is called as soon as a transport has accepted an
+	// inbound connection, before it's been multiplexed or secured.
+	InterceptAccept(addrs ConnMultiaddrs) (allow bool)
+
+	// InterceptSecured is called after the security handshake completes
+	// on an inbound or outbound connection, once the remote peer ID is
+	// known but before stream multiplexing negotiation.
+	InterceptSecured(dir inet.Direction, p peer.ID, addrs ConnMultiaddrs) (allow bool)
+
+	// InterceptUpgraded is called after a connection has completed its
+	// full upgrade (security and muxer negotiation). Returning false
+	// closes the connection immediately, tagged with reason.
+	InterceptUpgraded(conn inet.Conn) (allow bool, reason control.DisconnectReason)
+}
@@ -0,0 +1,187 @@
+// Package autonat implements a lightweight AutoNAT client: a background
+// probe that asks already-connected peers to dial us back on our observed
+// addresses, classifying this host as Public or Private depending on
+// whether those dial-backs succeed.
+package autonat
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	host "github.com/dms3-p2p/go-p2p-host"
+	protocol "github.com/dms3-p2p/go-p2p-protocol"
+)
+
+// AutoNATProto is the protocol ID the dial-back service is served on (see
+// NewAutoNATService).
+const AutoNATProto protocol.ID = "/dms3-p2p/autonat/1.0.0"
+
+// probeInterval is how often a client re-evaluates its reachability.
+const probeInterval = 15 * time.Minute
+
+// maxProbePeers is the maximum number of peers, per round, that a probe
+// will actually ask for a dial-back (peers that don't speak AutoNATProto
+// don't count against this - we just move on to the next candidate).
+const maxProbePeers = 3
+
+// maxScanPeers bounds how many connected peers a round is willing to try
+// before giving up on finding maxProbePeers that speak AutoNATProto.
+const maxScanPeers = 8
+
+// privateConfirmationRounds is how many consecutive rounds must see every
+// reachable peer reject our dial-back before the status flips to Private.
+// A single uncooperative round (most peers not running the NAT service, or
+// one spurious rejection) isn't enough on its own.
+const privateConfirmationRounds = 3
+
+// Reachability is a host's best guess at whether it's publicly dialable.
+type Reachability int
+
+const (
+	// ReachabilityUnknown means no successful probe has completed yet.
+	ReachabilityUnknown Reachability = iota
+	// ReachabilityPublic means at least one peer has dialed us back
+	// successfully on a non-relay address.
+	ReachabilityPublic
+	// ReachabilityPrivate means recent dial-back attempts have failed.
+	ReachabilityPrivate
+)
+
+// String implements fmt.Stringer.
+func (r Reachability) String() string {
+	switch r {
+	case ReachabilityPublic:
+		return "Public"
+	case ReachabilityPrivate:
+		return "Private"
+	default:
+		return "Unknown"
+	}
+}
+
+// AutoNAT is a background task that periodically asks connected peers to
+// dial us back, to determine whether this host is publicly reachable.
+type AutoNAT struct {
+	host host.Host
+
+	mu         sync.Mutex
+	status     Reachability
+	rejections int
+}
+
+// New starts an AutoNAT client for h. The returned AutoNAT's Status begins
+// as ReachabilityUnknown until the first probe round completes.
+func New(ctx context.Context, h host.Host) *AutoNAT {
+	nat := &AutoNAT{host: h}
+	go nat.background(ctx)
+	return nat
+}
+
+// Status returns the most recently determined reachability.
+func (nat *AutoNAT) Status() Reachability {
+	nat.mu.Lock()
+	defer nat.mu.Unlock()
+	return nat.status
+}
+
+func (nat *AutoNAT) background(ctx context.Context) {
+	// Probe shortly after startup so AutoRelay doesn't have to wait a
+	// full probeInterval before it learns whether it needs to find
+	// relays, then settle into the steady-state interval.
+	timer := time.NewTimer(time.Second)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			nat.probe(ctx)
+			timer.Reset(probeInterval)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// probe asks up to maxProbePeers connected peers that speak AutoNATProto to
+// dial us back. A single successful dial-back is enough to call the status
+// Public immediately. Peers we can't even open a stream to (because they
+// don't support AutoNATProto, or because the dial itself failed) are
+// skipped rather than counted as evidence of anything: that's most peers
+// in a typical swarm, and treating "nobody answered" the same as "everybody
+// said no" would flip a genuinely public host to Private on nearly every
+// round. A round only counts toward the Private verdict if every single
+// peer that answered explicitly rejected the dial-back (a stream-level
+// hiccup talking to a peer that does speak the protocol doesn't count as a
+// rejection, but it does spoil unanimity for that round); even then, the
+// status only moves to Private after privateConfirmationRounds consecutive
+// rounds were unanimous rejections.
+func (nat *AutoNAT) probe(ctx context.Context) {
+	candidates := nat.host.Network().Peers()
+	if len(candidates) == 0 {
+		return
+	}
+	rand.Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+	if len(candidates) > maxScanPeers {
+		candidates = candidates[:maxScanPeers]
+	}
+
+	responses, rejections := 0, 0
+	for _, p := range candidates {
+		if responses >= maxProbePeers {
+			break
+		}
+
+		s, err := nat.host.NewStream(ctx, p, AutoNATProto)
+		if err != nil {
+			// Doesn't speak AutoNATProto, or we couldn't dial it at all -
+			// inconclusive, try the next candidate instead of counting it.
+			continue
+		}
+		err = requestDialback(s, nat.host.Addrs())
+		s.Close()
+		responses++
+
+		if err == nil {
+			nat.resolve(ReachabilityPublic)
+			return
+		}
+		if err == errDialbackRejected {
+			rejections++
+		}
+		// Any other error is a stream-level hiccup talking to a peer that
+		// does speak the protocol; it still used up a response slot, but
+		// doesn't count toward the rejection streak either way.
+	}
+
+	if responses == 0 || rejections != responses {
+		// Either nobody we could actually ask responded, or at least one
+		// response wasn't an explicit rejection - not unanimous, so leave
+		// the verdict and the rejection streak exactly as they were.
+		return
+	}
+	nat.recordRejection()
+}
+
+// resolve sets the status to r and clears any rejection streak.
+func (nat *AutoNAT) resolve(r Reachability) {
+	nat.mu.Lock()
+	nat.status = r
+	nat.rejections = 0
+	nat.mu.Unlock()
+}
+
+// recordRejection counts one round where every peer that answered rejected
+// our dial-back addresses, flipping the status to Private only once
+// privateConfirmationRounds consecutive rounds have done so.
+func (nat *AutoNAT) recordRejection() {
+	nat.mu.Lock()
+	defer nat.mu.Unlock()
+	nat.rejections++
+	if nat.rejections >= privateConfirmationRounds {
+		nat.status = ReachabilityPrivate
+	}
+}
@@ -0,0 +1,34 @@
+package autonat
+
+import "testing"
+
+func TestRecordRejectionRequiresConsecutiveRounds(t *testing.T) {
+	nat := &AutoNAT{}
+	for i := 0; i < privateConfirmationRounds-1; i++ {
+		nat.recordRejection()
+		if nat.Status() == ReachabilityPrivate {
+			t.Fatalf("expected status to stay non-Private before %d rejection rounds, flipped after %d", privateConfirmationRounds, i+1)
+		}
+	}
+	nat.recordRejection()
+	if nat.Status() != ReachabilityPrivate {
+		t.Fatalf("expected status to flip to Private after %d consecutive rejection rounds", privateConfirmationRounds)
+	}
+}
+
+func TestResolvePublicClearsRejectionStreak(t *testing.T) {
+	nat := &AutoNAT{}
+	nat.recordRejection()
+	nat.resolve(ReachabilityPublic)
+	if nat.Status() != ReachabilityPublic {
+		t.Fatal("expected resolve to set the status")
+	}
+
+	// A single subsequent rejection round shouldn't be enough to flip back
+	// to Private - resolve must have cleared the streak recordRejection
+	// was accumulating.
+	nat.recordRejection()
+	if nat.Status() == ReachabilityPrivate {
+		t.Fatal("expected the rejection streak to have been cleared by resolve")
+	}
+}
@@ -0,0 +1,45 @@
+package autonat
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+
+	inet "github.com/dms3-p2p/go-p2p-net"
+	ma "github.com/dms3-mft/go-multiaddr"
+)
+
+// errDialbackRejected is returned by requestDialback when the peer on the
+// other end explicitly reported that none of our addresses were dialable -
+// a real signal about our reachability, unlike a stream/IO error (which
+// just means we couldn't complete the exchange with that peer at all).
+var errDialbackRejected = errors.New("autonat: peer reported our addresses aren't dialable")
+
+// requestDialback sends our candidate addresses to the peer on the other
+// end of s and waits for it to confirm (or deny) that one of them is
+// publicly dialable. The wire format is intentionally simple: one address
+// per line, terminated by a blank line, followed by a single "OK"/"FAIL"
+// response line; see AutoNATService.handleStream for the server side.
+func requestDialback(s inet.Stream, addrs []ma.Multiaddr) error {
+	w := bufio.NewWriter(s)
+	for _, a := range addrs {
+		if _, err := fmt.Fprintf(w, "%s\n", a); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprint(w, "\n"); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	line, err := bufio.NewReader(s).ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if line != "OK\n" {
+		return errDialbackRejected
+	}
+	return nil
+}
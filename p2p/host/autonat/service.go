@@ -0,0 +1,113 @@
+package autonat
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"time"
+
+	host "github.com/dms3-p2p/go-p2p-host"
+	inet "github.com/dms3-p2p/go-p2p-net"
+	ma "github.com/dms3-mft/go-multiaddr"
+	manet "github.com/dms3-mft/go-multiaddr-net"
+)
+
+// dialbackTimeout bounds how long the service will wait for any one
+// candidate address to connect.
+const dialbackTimeout = 15 * time.Second
+
+// dialbackReadTimeout bounds how long the service will wait, in total, for
+// a requesting peer to finish sending its address list.
+const dialbackReadTimeout = 10 * time.Second
+
+// maxDialbackLines bounds how many lines readDialbackRequest will read
+// looking for the blank-line terminator, so a peer that never sends one
+// can't pin the handler goroutine open indefinitely.
+const maxDialbackLines = 64
+
+// AutoNATService answers AutoNATProto requests from other peers by dialing
+// the addresses they claim, and reporting back whether any of them were
+// reachable. A host opts into running one via the EnableNATService option.
+type AutoNATService struct {
+	host host.Host
+}
+
+// NewAutoNATService installs an AutoNATProto stream handler on h that
+// performs dial-back probes on behalf of requesting peers. This is what
+// `EnableNATService` wires up.
+func NewAutoNATService(ctx context.Context, h host.Host) (*AutoNATService, error) {
+	as := &AutoNATService{host: h}
+	h.SetStreamHandler(AutoNATProto, as.handleStream)
+	return as, nil
+}
+
+func (as *AutoNATService) handleStream(s inet.Stream) {
+	defer s.Close()
+
+	addrs, err := readDialbackRequest(s)
+	if err != nil {
+		return
+	}
+
+	ok := as.tryDial(addrs)
+	w := bufio.NewWriter(s)
+	if ok {
+		fmt.Fprint(w, "OK\n")
+	} else {
+		fmt.Fprint(w, "FAIL\n")
+	}
+	w.Flush()
+}
+
+// tryDial attempts a raw (unauthenticated) dial to each candidate address in
+// turn, returning true on the first one that succeeds. This deliberately
+// bypasses dms3-p2p's transport upgrader: all we're checking is whether the
+// address is routable from the outside, not whether a full handshake
+// succeeds. Loopback and private-range addresses are skipped outright: a
+// successful dial to an address the client itself supplied only means
+// something if that address could plausibly be reached from outside the
+// client's own network.
+func (as *AutoNATService) tryDial(addrs []ma.Multiaddr) bool {
+	for _, a := range addrs {
+		if !manet.IsPublicAddr(a) {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), dialbackTimeout)
+		conn, err := manet.DialContext(ctx, a)
+		cancel()
+		if err != nil {
+			continue
+		}
+		conn.Close()
+		return true
+	}
+	return false
+}
+
+// readDialbackRequest reads the requesting peer's address list: one
+// multiaddr per line, terminated by a blank line (see requestDialback for
+// the wire format). It bounds both how long it'll wait (dialbackReadTimeout)
+// and how many lines it'll read (maxDialbackLines), since s comes from an
+// untrusted peer that could otherwise hold the handler goroutine open
+// forever by never sending the terminator.
+func readDialbackRequest(s inet.Stream) ([]ma.Multiaddr, error) {
+	if err := s.SetReadDeadline(time.Now().Add(dialbackReadTimeout)); err != nil {
+		return nil, err
+	}
+
+	var addrs []ma.Multiaddr
+	r := bufio.NewReader(s)
+	for i := 0; i < maxDialbackLines; i++ {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		if line == "\n" {
+			return addrs, nil
+		}
+		if a, err := ma.NewMultiaddr(line[:len(line)-1]); err == nil {
+			addrs = append(addrs, a)
+		}
+	}
+	return nil, fmt.Errorf("autonat: dial-back request exceeded %d lines without a terminator", maxDialbackLines)
+}
@@ -0,0 +1,152 @@
+// Package autorelay implements the client side of dms3-p2p's relay
+// discovery: when a host finds itself Private (per autonat.AutoNAT), it
+// connects to a set of relays (static, discovered, or both) and advertises
+// reachable /p2p-circuit addresses through those relays instead of its
+// (unreachable) direct ones.
+package autorelay
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	autonat "github.com/dms3-p2p/go-p2p/p2p/host/autonat"
+
+	circuit "github.com/dms3-p2p/go-p2p-circuit"
+	host "github.com/dms3-p2p/go-p2p-host"
+	pstore "github.com/dms3-p2p/go-p2p-peerstore"
+	ma "github.com/dms3-mft/go-multiaddr"
+)
+
+// findRelaysInterval is how often AutoRelay re-checks its reachability and,
+// if still Private, tops up its set of connected relays.
+const findRelaysInterval = 5 * time.Minute
+
+// desiredRelays is the number of relays AutoRelay tries to stay connected
+// to at once.
+const desiredRelays = 3
+
+// Discoverer is a user-supplied function that returns a stream of
+// candidate relay peers. AutoRelay drains it until it has enough relays or
+// the channel closes.
+type Discoverer func(ctx context.Context) (<-chan pstore.PeerInfo, error)
+
+// reachabilityStatus is the subset of autonat.AutoNAT that AutoRelay needs;
+// it exists so tests can fake reachability without a real AutoNAT client.
+type reachabilityStatus interface {
+	Status() autonat.Reachability
+}
+
+// AutoRelay discovers relays and advertises /p2p-circuit addresses through
+// them whenever the host is Private. See the EnableAutoRelay option.
+type AutoRelay struct {
+	host     host.Host
+	autonat  reachabilityStatus
+	static   []pstore.PeerInfo
+	discover Discoverer
+
+	mu     sync.Mutex
+	relays []pstore.PeerInfo
+}
+
+// NewAutoRelay starts the AutoRelay background loop for h. static is always
+// tried first; discover (optional) is used to find additional relays when
+// the static list isn't enough.
+func NewAutoRelay(ctx context.Context, h host.Host, nat *autonat.AutoNAT, static []pstore.PeerInfo, discover Discoverer) *AutoRelay {
+	ar := &AutoRelay{
+		host:     h,
+		autonat:  nat,
+		static:   static,
+		discover: discover,
+	}
+	go ar.background(ctx)
+	return ar
+}
+
+func (ar *AutoRelay) background(ctx context.Context) {
+	ticker := time.NewTicker(findRelaysInterval)
+	defer ticker.Stop()
+
+	ar.findRelays(ctx)
+	for {
+		select {
+		case <-ticker.C:
+			ar.findRelays(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// findRelays tops up ar.relays, if the host is Private and we're short of
+// desiredRelays, by connecting to static relays first and then, if needed,
+// draining the discover function.
+func (ar *AutoRelay) findRelays(ctx context.Context) {
+	if ar.autonat.Status() != autonat.ReachabilityPrivate {
+		return
+	}
+
+	ar.mu.Lock()
+	have := len(ar.relays)
+	ar.mu.Unlock()
+	if have >= desiredRelays {
+		return
+	}
+
+	candidates := make([]pstore.PeerInfo, 0, len(ar.static))
+	candidates = append(candidates, ar.static...)
+
+	if len(candidates) < desiredRelays && ar.discover != nil {
+		ch, err := ar.discover(ctx)
+		if err == nil {
+			for pi := range ch {
+				candidates = append(candidates, pi)
+				if len(candidates) >= desiredRelays {
+					break
+				}
+			}
+		}
+	}
+
+	for _, pi := range candidates {
+		if err := ar.host.Connect(ctx, pi); err != nil {
+			continue
+		}
+		ar.mu.Lock()
+		ar.relays = append(ar.relays, pi)
+		ar.mu.Unlock()
+		if len(ar.relays) >= desiredRelays {
+			break
+		}
+	}
+}
+
+// relayAddrs returns our current relays' addresses, rewritten as
+// /p2p-circuit addrs through which we're reachable.
+func (ar *AutoRelay) relayAddrs() []ma.Multiaddr {
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+
+	var out []ma.Multiaddr
+	for _, relay := range ar.relays {
+		for _, a := range relay.Addrs {
+			circuitAddr, err := circuit.NewRelayAddr(relay.ID, a, ar.host.ID())
+			if err != nil {
+				continue
+			}
+			out = append(out, circuitAddr)
+		}
+	}
+	return out
+}
+
+// AddrsFactory is an AddrsFactory-shaped function: when the host is
+// Private, it appends our relay addresses to (rather than replacing) the
+// addresses it's given, so it composes with any previously configured
+// AddrsFactory.
+func (ar *AutoRelay) AddrsFactory(addrs []ma.Multiaddr) []ma.Multiaddr {
+	if ar.autonat.Status() != autonat.ReachabilityPrivate {
+		return addrs
+	}
+	return append(addrs, ar.relayAddrs()...)
+}
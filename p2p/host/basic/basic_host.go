@@ -0,0 +1,210 @@
+// Package basic provides the default, concrete host.Host implementation
+// used by dms3-p2p: BasicHost wires a network.Network together with a
+// connection manager, NAT manager, and an AddrsFactory that controls which
+// addresses get advertised to the rest of the network.
+package basic
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	autonat "github.com/dms3-p2p/go-p2p/p2p/host/autonat"
+
+	ifconnmgr "github.com/dms3-p2p/go-p2p-interface-connmgr"
+	inet "github.com/dms3-p2p/go-p2p-net"
+	peer "github.com/dms3-p2p/go-p2p-peer"
+	pstore "github.com/dms3-p2p/go-p2p-peerstore"
+	protocol "github.com/dms3-p2p/go-p2p-protocol"
+	ma "github.com/dms3-mft/go-multiaddr"
+	msmux "github.com/dms3-mft/go-multistream"
+)
+
+// AddrsFactory functions take the addresses a host is listening on and
+// return the addresses that should be advertised to the network. dms3-p2p's
+// `AddrsFactory` option and `AnnounceAddrs` helper both produce one of
+// these.
+type AddrsFactory func([]ma.Multiaddr) []ma.Multiaddr
+
+// HostOpts holds the options accepted by NewHost.
+type HostOpts struct {
+	ConnManager  ifconnmgr.ConnManager
+	AddrsFactory AddrsFactory
+	NATManager   NATManagerC
+}
+
+// BasicHost is the default dms3-p2p host.Host implementation, built directly
+// on top of a network.Network.
+type BasicHost struct {
+	network inet.Network
+	mux     *msmux.MultistreamMuxer
+
+	connMgr      ifconnmgr.ConnManager
+	addrsFactory AddrsFactory
+
+	natmgr NATManager
+
+	autonat *autonat.AutoNAT
+}
+
+// NewHost constructs a BasicHost around the given network.
+func NewHost(ctx context.Context, net inet.Network, opts *HostOpts) (*BasicHost, error) {
+	if opts == nil {
+		opts = &HostOpts{}
+	}
+
+	h := &BasicHost{
+		network:      net,
+		mux:          msmux.NewMultistreamMuxer(),
+		connMgr:      opts.ConnManager,
+		addrsFactory: opts.AddrsFactory,
+	}
+	h.network.SetStreamHandler(h.newStreamHandler)
+
+	if opts.NATManager != nil {
+		h.natmgr = opts.NATManager(net)
+	}
+
+	return h, nil
+}
+
+// newStreamHandler is the single handler BasicHost registers on its network;
+// every inbound stream comes through here and gets dispatched to whichever
+// protocol handler(s) registered via SetStreamHandler negotiate successfully
+// over multistream-select.
+func (h *BasicHost) newStreamHandler(s inet.Stream) {
+	selected, handle, err := h.mux.Negotiate(s)
+	if err != nil {
+		s.Reset()
+		return
+	}
+	s.SetProtocol(protocol.ID(selected))
+	handle(selected, s)
+}
+
+// ID returns the peer ID of this host.
+func (h *BasicHost) ID() peer.ID {
+	return h.network.LocalPeer()
+}
+
+// Peerstore returns the Peerstore backing this host's network.
+func (h *BasicHost) Peerstore() pstore.Peerstore {
+	return h.network.Peerstore()
+}
+
+// Network returns the network.Network backing this host.
+func (h *BasicHost) Network() inet.Network {
+	return h.network
+}
+
+// ConnManager returns the connection manager configured for this host, if
+// any.
+func (h *BasicHost) ConnManager() ifconnmgr.ConnManager {
+	return h.connMgr
+}
+
+// Addrs returns the addresses this host is listening on, filtered through
+// the configured AddrsFactory if any.
+func (h *BasicHost) Addrs() []ma.Multiaddr {
+	addrs := h.network.ListenAddresses()
+	if h.addrsFactory != nil {
+		return h.addrsFactory(addrs)
+	}
+	return addrs
+}
+
+// SetAddrsFactory replaces the AddrsFactory used by Addrs. It's used by the
+// AutoRelay subsystem to wrap whatever factory the host was configured with
+// so that relay addrs get added on top instead of replacing it.
+func (h *BasicHost) SetAddrsFactory(af AddrsFactory) {
+	h.addrsFactory = af
+}
+
+// SetAutoNAT attaches the AutoNAT client this host should report
+// Reachability through. It's called once, during construction, by
+// NewNode if AutoNAT or AutoRelay was enabled.
+func (h *BasicHost) SetAutoNAT(nat *autonat.AutoNAT) {
+	h.autonat = nat
+}
+
+// AutoNAT returns the AutoNAT client attached to this host, or nil if
+// AutoNAT wasn't enabled.
+func (h *BasicHost) AutoNAT() *autonat.AutoNAT {
+	return h.autonat
+}
+
+// Reachability reports this host's best guess at whether it's publicly
+// dialable, as determined by its AutoNAT client. It returns
+// autonat.ReachabilityUnknown if AutoNAT wasn't enabled (see
+// EnableAutoRelay / EnableNATService).
+func (h *BasicHost) Reachability() autonat.Reachability {
+	if h.autonat == nil {
+		return autonat.ReachabilityUnknown
+	}
+	return h.autonat.Status()
+}
+
+// Connect ensures there's a connection to the given peer, dialing it if
+// necessary.
+func (h *BasicHost) Connect(ctx context.Context, pi pstore.PeerInfo) error {
+	if len(pi.Addrs) > 0 {
+		h.Peerstore().AddAddrs(pi.ID, pi.Addrs, pstore.TempAddrTTL)
+	}
+	_, err := h.network.DialPeer(ctx, pi.ID)
+	return err
+}
+
+// NewStream opens a new stream to the given peer and negotiates it down to
+// one of the given protocol IDs via multistream-select, in the order given.
+// The stream's negotiated protocol is recorded on it (SetProtocol) before
+// it's returned.
+func (h *BasicHost) NewStream(ctx context.Context, p peer.ID, pids ...protocol.ID) (inet.Stream, error) {
+	if len(pids) == 0 {
+		return nil, fmt.Errorf("NewStream: no protocol IDs given")
+	}
+
+	s, err := h.network.NewStream(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	strs := make([]string, len(pids))
+	for i, pid := range pids {
+		strs[i] = string(pid)
+	}
+
+	selected, err := msmux.SelectOneOf(strs, s)
+	if err != nil {
+		s.Reset()
+		return nil, err
+	}
+	s.SetProtocol(protocol.ID(selected))
+	return s, nil
+}
+
+// SetStreamHandler registers handler to be called on any inbound stream
+// that negotiates down to pid over multistream-select. Registering a
+// second handler for the same pid replaces the first, exactly like
+// multistream's own AddHandler; handlers for other protocols are
+// unaffected.
+func (h *BasicHost) SetStreamHandler(pid protocol.ID, handler inet.StreamHandler) {
+	h.mux.AddHandler(string(pid), func(_ string, rwc io.ReadWriteCloser) error {
+		handler(rwc.(inet.Stream))
+		return nil
+	})
+}
+
+// RemoveStreamHandler removes the registered handler for the given
+// protocol, if any.
+func (h *BasicHost) RemoveStreamHandler(pid protocol.ID) {
+	h.mux.RemoveHandler(string(pid))
+}
+
+// Close shuts down the host, including its NAT manager (if any) and its
+// underlying network.
+func (h *BasicHost) Close() error {
+	if h.natmgr != nil {
+		h.natmgr.Close()
+	}
+	return h.network.Close()
+}
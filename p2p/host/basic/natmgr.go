@@ -0,0 +1,27 @@
+package basic
+
+import (
+	inet "github.com/dms3-p2p/go-p2p-net"
+	nat "github.com/dms3-p2p/go-nat"
+)
+
+// NATManager is a NAT helper that a BasicHost constructs (from
+// HostOpts.NATManager) and closes along with itself.
+type NATManager interface {
+	// NAT returns the NAT device, if one has been found.
+	NAT() *nat.NAT
+	// Close stops the NAT manager's background discovery.
+	Close() error
+}
+
+// NATManagerC is the type of function that constructs a NATManager, given
+// the network it should manage port mappings for.
+type NATManagerC func(inet.Network) NATManager
+
+// NewNATManager returns a NATManager that discovers the NAT device on the
+// local network, if any. It doesn't open port mappings for net's listen
+// addresses. This is dms3-p2p's default NATManager, used by the NATPortMap
+// option.
+func NewNATManager(net inet.Network) NATManager {
+	return newNATManager(net)
+}
@@ -0,0 +1,52 @@
+package basic
+
+import (
+	"context"
+	"sync"
+
+	inet "github.com/dms3-p2p/go-p2p-net"
+	nat "github.com/dms3-p2p/go-nat"
+)
+
+// natManager discovers a NAT device on the local network (if any). It
+// doesn't create or maintain port mappings for the host's listen addresses.
+type natManager struct {
+	net inet.Network
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu  sync.Mutex
+	nat *nat.NAT
+}
+
+func newNATManager(net inet.Network) *natManager {
+	ctx, cancel := context.WithCancel(context.Background())
+	nm := &natManager{net: net, ctx: ctx, cancel: cancel}
+	go nm.discover()
+	return nm
+}
+
+func (nm *natManager) discover() {
+	discovered, err := nat.DiscoverNAT(nm.ctx)
+	if err != nil {
+		return
+	}
+	nm.mu.Lock()
+	nm.nat = discovered
+	nm.mu.Unlock()
+}
+
+// NAT returns the discovered NAT device, or nil if discovery hasn't
+// completed (or found nothing).
+func (nm *natManager) NAT() *nat.NAT {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+	return nm.nat
+}
+
+// Close stops NAT discovery.
+func (nm *natManager) Close() error {
+	nm.cancel()
+	return nil
+}
@@ -3,14 +3,31 @@ package p2p
 import (
 	"context"
 	"fmt"
+	"net"
 	"regexp"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	config "github.com/dms3-p2p/go-p2p/config"
+	connmgr "github.com/dms3-p2p/go-p2p/p2p/connmgr"
+	control "github.com/dms3-p2p/go-p2p/p2p/connmgr/control"
+	autonat "github.com/dms3-p2p/go-p2p/p2p/host/autonat"
 
 	crypto "github.com/dms3-p2p/go-p2p-crypto"
 	host "github.com/dms3-p2p/go-p2p-host"
+	inet "github.com/dms3-p2p/go-p2p-net"
+	mux "github.com/dms3-p2p/go-p2p-interface-muxer"
+	peer "github.com/dms3-p2p/go-p2p-peer"
 	pstore "github.com/dms3-p2p/go-p2p-peerstore"
+	secio "github.com/dms3-p2p/go-p2p-secio"
+	security "github.com/dms3-p2p/go-p2p-security"
 	"github.com/dms3-p2p/go-tcp-transport"
+	ma "github.com/dms3-mft/go-multiaddr"
+	mplex "github.com/dms3-why/go-smux-multiplex"
+	yamux "github.com/dms3-why/go-smux-yamux"
+	fx "go.uber.org/fx"
 )
 
 func TestNewHost(t *testing.T) {
@@ -21,6 +38,26 @@ func TestNewHost(t *testing.T) {
 	h.Close()
 }
 
+// TestNewNodeListenFailureStopsApp asserts that a listen failure (here, two
+// hosts fighting over the same fixed port) doesn't leak the fx.App that
+// New already started constructing - the second New call should return an
+// error, not a host whose underlying swarm/goroutines were never stopped.
+func TestNewNodeListenFailureStopsApp(t *testing.T) {
+	ctx := context.Background()
+
+	first, err := makeRandomHost(t, 9010)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+
+	second, err := New(ctx, ListenAddrStrings("/ip4/127.0.0.1/tcp/9010"))
+	if err == nil {
+		second.Close()
+		t.Fatal("expected an error binding the already-occupied port")
+	}
+}
+
 func TestBadTransportConstructor(t *testing.T) {
 	ctx := context.Background()
 	h, err := New(ctx, Transport(func() {}))
@@ -110,6 +147,483 @@ func TestDefaultListenAddrs(t *testing.T) {
 	h.Close()
 }
 
+// TestStreamProtocolDispatch asserts that SetStreamHandler/NewStream
+// actually negotiate and route by protocol ID, instead of sharing a single
+// network-level handler: two protocols registered on the listener must
+// each reach only their own handler, and dialing one of them must not
+// trigger the other.
+func TestStreamProtocolDispatch(t *testing.T) {
+	ctx := context.Background()
+
+	listener, err := New(ctx, ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	var pingHits, echoHits int
+	listener.SetStreamHandler("/ping/1.0.0", func(s inet.Stream) {
+		pingHits++
+		s.Close()
+	})
+	listener.SetStreamHandler("/echo/1.0.0", func(s inet.Stream) {
+		echoHits++
+		s.Close()
+	})
+
+	dialer, err := New(ctx, ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dialer.Close()
+
+	if err := dialer.Connect(ctx, pstore.PeerInfo{ID: listener.ID(), Addrs: listener.Addrs()}); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := dialer.NewStream(ctx, listener.ID(), "/echo/1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	// Give the listener's background negotiation handler a moment to run.
+	time.Sleep(100 * time.Millisecond)
+
+	if echoHits != 1 || pingHits != 0 {
+		t.Fatalf("expected exactly the echo handler to fire once, got ping=%d echo=%d", pingHits, echoHits)
+	}
+}
+
+func TestNoTCPTransport(t *testing.T) {
+	ctx := context.Background()
+	h, err := New(ctx, NoTCP, ListenAddrStrings("/ip4/127.0.0.1/tcp/0", "/ip4/127.0.0.1/tcp/0/ws"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	addrs := h.Network().ListenAddresses()
+	if len(addrs) != 1 || !strings.HasSuffix(addrs[0].String(), "/ws") {
+		t.Errorf("expected only the Websocket listen addr with TCP disabled, got %v", addrs)
+	}
+}
+
+// TestAnnounceAddrs covers AnnounceAddrs' three documented behaviors: the
+// empty-announce fallback to listen addrs, noAnnounce CIDR filtering, and
+// composing with a pre-set AddrsFactory rather than rejecting it.
+func TestAnnounceAddrs(t *testing.T) {
+	addr := func(s string) ma.Multiaddr {
+		a, err := ma.NewMultiaddr(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return a
+	}
+	listen := []ma.Multiaddr{addr("/ip4/127.0.0.1/tcp/1234"), addr("/ip4/10.0.0.5/tcp/1234")}
+
+	t.Run("empty announce falls back to listen addrs", func(t *testing.T) {
+		var cfg Config
+		if err := cfg.Apply(AnnounceAddrs(nil, nil)); err != nil {
+			t.Fatal(err)
+		}
+		if got := cfg.AddrsFactory(listen); len(got) != len(listen) {
+			t.Fatalf("expected %d addrs, got %d", len(listen), len(got))
+		}
+	})
+
+	t.Run("non-empty announce overrides listen addrs", func(t *testing.T) {
+		announce := []ma.Multiaddr{addr("/ip4/1.2.3.4/tcp/4001")}
+		var cfg Config
+		if err := cfg.Apply(AnnounceAddrs(announce, nil)); err != nil {
+			t.Fatal(err)
+		}
+		got := cfg.AddrsFactory(listen)
+		if len(got) != 1 || !got[0].Equal(announce[0]) {
+			t.Fatalf("expected exactly the announce addr, got %v", got)
+		}
+	})
+
+	t.Run("noAnnounce strips matching CIDRs", func(t *testing.T) {
+		_, ipnet, err := net.ParseCIDR("10.0.0.0/8")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var cfg Config
+		if err := cfg.Apply(AnnounceAddrs(nil, []*net.IPNet{ipnet})); err != nil {
+			t.Fatal(err)
+		}
+		got := cfg.AddrsFactory(listen)
+		if len(got) != 1 || strings.Contains(got[0].String(), "10.0.0.5") {
+			t.Fatalf("expected 10.0.0.5 to be filtered out, got %v", got)
+		}
+	})
+
+	t.Run("combines with a pre-set AddrsFactory", func(t *testing.T) {
+		var cfg Config
+		extra := addr("/ip4/9.9.9.9/tcp/4001")
+		err := cfg.Apply(
+			AddrsFactory(func(addrs []ma.Multiaddr) []ma.Multiaddr {
+				return append(addrs, extra)
+			}),
+			AnnounceAddrs(nil, nil),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := cfg.AddrsFactory(listen); len(got) != len(listen)+1 {
+			t.Fatalf("expected the pre-set factory's addr to survive, got %v", got)
+		}
+	})
+}
+
+func TestParseCIDRMultiaddr(t *testing.T) {
+	ipnet, err := ParseCIDRMultiaddr("/ip4/10.0.0.0/ipcidr/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ones, _ := ipnet.Mask.Size(); ones != 8 {
+		t.Fatalf("expected a /8 mask, got /%d", ones)
+	}
+
+	if _, err := ParseCIDRMultiaddr("/ip4/10.0.0.0/tcp/8"); err == nil {
+		t.Fatal("expected an error for a non-ipcidr mask string")
+	}
+}
+
+// TestEnableNATService asserts that EnableNATService starts an AutoNAT
+// client whose Reachability is queryable through the host, even before any
+// probe has completed.
+func TestEnableNATService(t *testing.T) {
+	ctx := context.Background()
+	h, err := New(ctx, EnableNATService())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	type reachable interface {
+		Reachability() autonat.Reachability
+	}
+	rh, ok := h.(reachable)
+	if !ok {
+		t.Fatal("expected host to expose a Reachability accessor")
+	}
+	if rh.Reachability() != autonat.ReachabilityUnknown {
+		t.Errorf("expected ReachabilityUnknown before any probe has run, got %s", rh.Reachability())
+	}
+}
+
+// recordingMuxer wraps a mux.Transport, noting whether multistream
+// negotiation actually picked it to build the muxed connection. Embedding
+// mux.Transport satisfies the interface for every method we don't override;
+// NewConn is the one call every mux.Transport makes to actually wrap the raw
+// connection, so it's the point at which "this muxer won" is observable.
+type recordingMuxer struct {
+	mux.Transport
+	used int32
+}
+
+func (m *recordingMuxer) NewConn(c net.Conn, isServer bool) (mux.MuxedConn, error) {
+	atomic.StoreInt32(&m.used, 1)
+	return m.Transport.NewConn(c, isServer)
+}
+
+func (m *recordingMuxer) wasUsed() bool {
+	return atomic.LoadInt32(&m.used) == 1
+}
+
+// TestMuxerPriority spins up two hosts that each support both yamux and
+// mplex, but disagree on which one should be preferred, and asserts - by
+// reading back which recordingMuxer actually built the muxed connection on
+// both ends - that the dialer's priority order wins the multistream
+// negotiation.
+func TestMuxerPriority(t *testing.T) {
+	ctx := context.Background()
+
+	dialerYamux := &recordingMuxer{Transport: yamux.DefaultTransport}
+	dialerMplex := &recordingMuxer{Transport: mplex.DefaultTransport}
+
+	dialerMuxers := config.TransportConfig{}
+	dialerMuxers.SetMuxerPriority("/mplex/6.7.0", 0)
+	dialerMuxers.SetMuxerPriority("/yamux/1.0.0", 1)
+
+	dialer, err := New(ctx,
+		ListenAddrStrings("/ip4/127.0.0.1/tcp/0"),
+		Muxer("/yamux/1.0.0", dialerYamux),
+		Muxer("/mplex/6.7.0", dialerMplex),
+		Transports(dialerMuxers),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dialer.Close()
+
+	listenerYamux := &recordingMuxer{Transport: yamux.DefaultTransport}
+	listenerMplex := &recordingMuxer{Transport: mplex.DefaultTransport}
+
+	listenerMuxers := config.TransportConfig{}
+	listenerMuxers.SetMuxerPriority("/yamux/1.0.0", 0)
+	listenerMuxers.SetMuxerPriority("/mplex/6.7.0", 1)
+
+	listener, err := New(ctx,
+		ListenAddrStrings("/ip4/127.0.0.1/tcp/0"),
+		Muxer("/yamux/1.0.0", listenerYamux),
+		Muxer("/mplex/6.7.0", listenerMplex),
+		Transports(listenerMuxers),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	if err := dialer.Connect(ctx, pstore.PeerInfo{
+		ID:    listener.ID(),
+		Addrs: listener.Addrs(),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if conns := dialer.Network().ConnsToPeer(listener.ID()); len(conns) == 0 {
+		t.Fatal("expected a connection to the listener despite mismatched muxer priorities")
+	}
+
+	if !dialerMplex.wasUsed() || dialerYamux.wasUsed() {
+		t.Fatalf("expected the dialer's mplex preference to win the negotiation, mplex used=%v yamux used=%v", dialerMplex.wasUsed(), dialerYamux.wasUsed())
+	}
+	if !listenerMplex.wasUsed() || listenerYamux.wasUsed() {
+		t.Fatalf("expected the listener to have negotiated mplex too, mplex used=%v yamux used=%v", listenerMplex.wasUsed(), listenerYamux.wasUsed())
+	}
+}
+
+// recordingSecurity wraps a security.Transport, noting whether it actually
+// performed the handshake on one side of a connection. See recordingMuxer
+// above for the same technique applied to stream multiplexers.
+type recordingSecurity struct {
+	security.Transport
+	used int32
+}
+
+func (s *recordingSecurity) SecureInbound(ctx context.Context, insecure net.Conn) (security.SecureConn, error) {
+	atomic.StoreInt32(&s.used, 1)
+	return s.Transport.SecureInbound(ctx, insecure)
+}
+
+func (s *recordingSecurity) SecureOutbound(ctx context.Context, insecure net.Conn, p peer.ID) (security.SecureConn, error) {
+	atomic.StoreInt32(&s.used, 1)
+	return s.Transport.SecureOutbound(ctx, insecure, p)
+}
+
+func (s *recordingSecurity) wasUsed() bool {
+	return atomic.LoadInt32(&s.used) == 1
+}
+
+// wrappedSecio builds a SecC that resolves secio.New the usual way and
+// wraps the result in a recordingSecurity, so tests can observe which of
+// several registered security protocol IDs actually negotiated.
+func wrappedSecio(t *testing.T, rec *recordingSecurity) func(host.Host) (security.Transport, error) {
+	ctor, err := config.SecurityConstructor(secio.New)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return func(h host.Host) (security.Transport, error) {
+		real, err := ctor(h)
+		if err != nil {
+			return nil, err
+		}
+		rec.Transport = real
+		return rec, nil
+	}
+}
+
+// TestSecurityPriority is the security-transport counterpart to
+// TestMuxerPriority: the same secio implementation is registered twice,
+// under two different protocol IDs, with each host preferring a different
+// one - and a recordingSecurity on each ID lets the test assert which one
+// actually performed the handshake rather than only checking that a
+// connection came up.
+func TestSecurityPriority(t *testing.T) {
+	ctx := context.Background()
+
+	dialerA := &recordingSecurity{}
+	dialerB := &recordingSecurity{}
+	dialerSec := config.TransportConfig{}
+	dialerSec.SetSecurityPriority("/secio-b/1.0.0", 0)
+	dialerSec.SetSecurityPriority("/secio-a/1.0.0", 1)
+
+	dialer, err := New(ctx,
+		ListenAddrStrings("/ip4/127.0.0.1/tcp/0"),
+		Security("/secio-a/1.0.0", wrappedSecio(t, dialerA)),
+		Security("/secio-b/1.0.0", wrappedSecio(t, dialerB)),
+		Transports(dialerSec),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dialer.Close()
+
+	listenerA := &recordingSecurity{}
+	listenerB := &recordingSecurity{}
+	listenerSec := config.TransportConfig{}
+	listenerSec.SetSecurityPriority("/secio-a/1.0.0", 0)
+	listenerSec.SetSecurityPriority("/secio-b/1.0.0", 1)
+
+	listener, err := New(ctx,
+		ListenAddrStrings("/ip4/127.0.0.1/tcp/0"),
+		Security("/secio-a/1.0.0", wrappedSecio(t, listenerA)),
+		Security("/secio-b/1.0.0", wrappedSecio(t, listenerB)),
+		Transports(listenerSec),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	if err := dialer.Connect(ctx, pstore.PeerInfo{
+		ID:    listener.ID(),
+		Addrs: listener.Addrs(),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if conns := dialer.Network().ConnsToPeer(listener.ID()); len(conns) == 0 {
+		t.Fatal("expected a connection to the listener despite mismatched security priorities")
+	}
+
+	if !dialerB.wasUsed() || dialerA.wasUsed() {
+		t.Fatalf("expected the dialer's secio-b preference to win the negotiation, b used=%v a used=%v", dialerB.wasUsed(), dialerA.wasUsed())
+	}
+	if !listenerB.wasUsed() || listenerA.wasUsed() {
+		t.Fatalf("expected the listener to have negotiated secio-b too, b used=%v a used=%v", listenerB.wasUsed(), listenerA.wasUsed())
+	}
+}
+
+// blockPeerGater is a connmgr.ConnectionGater that rejects a single,
+// configured peer ID at every phase; everything else is allowed.
+type blockPeerGater struct {
+	blocked peer.ID
+}
+
+func (g *blockPeerGater) InterceptPeerDial(p peer.ID) bool { return p != g.blocked }
+func (g *blockPeerGater) InterceptAddrDial(p peer.ID, _ ma.Multiaddr) bool {
+	return p != g.blocked
+}
+func (g *blockPeerGater) InterceptAccept(_ connmgr.ConnMultiaddrs) bool { return true }
+func (g *blockPeerGater) InterceptSecured(_ inet.Direction, p peer.ID, _ connmgr.ConnMultiaddrs) bool {
+	return p != g.blocked
+}
+func (g *blockPeerGater) InterceptUpgraded(_ inet.Conn) (bool, control.DisconnectReason) {
+	return true, 0
+}
+
+// TestConnectionGaterBlocksDial asserts that a ConnectionGater rejecting a
+// peer ID at InterceptPeerDial stops the dialer from ever connecting to it.
+func TestConnectionGaterBlocksDial(t *testing.T) {
+	ctx := context.Background()
+
+	listener, err := New(ctx, ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	dialer, err := New(ctx,
+		ListenAddrStrings("/ip4/127.0.0.1/tcp/0"),
+		ConnectionGater(&blockPeerGater{blocked: listener.ID()}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dialer.Close()
+
+	err = dialer.Connect(ctx, pstore.PeerInfo{
+		ID:    listener.ID(),
+		Addrs: listener.Addrs(),
+	})
+	if err == nil {
+		t.Fatal("expected dial to a gated peer ID to fail")
+	}
+}
+
+// TestConnectionGaterBlocksAccept asserts that a ConnectionGater rejecting a
+// peer ID at InterceptSecured causes the listener to reject that peer's
+// inbound connection even though the dialer itself is unrestricted.
+func TestConnectionGaterBlocksAccept(t *testing.T) {
+	ctx := context.Background()
+
+	dialer, err := New(ctx, ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dialer.Close()
+
+	listener, err := New(ctx,
+		ListenAddrStrings("/ip4/127.0.0.1/tcp/0"),
+		ConnectionGater(&blockPeerGater{blocked: dialer.ID()}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	err = dialer.Connect(ctx, pstore.PeerInfo{
+		ID:    listener.ID(),
+		Addrs: listener.Addrs(),
+	})
+	if err == nil {
+		t.Fatal("expected the listener to reject a gated peer's inbound connection")
+	}
+}
+
+// TestFxOption asserts that an fx.Option passed to Fx is actually spliced
+// into the fx.App NewNode assembles: here, an fx.Invoke that records
+// whether it ran.
+func TestFxOption(t *testing.T) {
+	ctx := context.Background()
+
+	var invoked bool
+	h, err := New(ctx, Fx(fx.Invoke(func(host.Host) {
+		invoked = true
+	})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	if !invoked {
+		t.Fatal("expected the fx.Option passed to Fx to run as part of host construction")
+	}
+}
+
+// TestFxOptionReplacesTransports asserts that the []config.TptC transport
+// list is itself resolved through the fx container, so a caller can swap it
+// out with fx.Replace instead of needing to fork the default Transport(...)
+// wiring.
+func TestFxOptionReplacesTransports(t *testing.T) {
+	ctx := context.Background()
+
+	tpt, err := config.TransportConstructor(tcp.NewTCPTransport)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := New(ctx,
+		NoTransports,
+		ListenAddrStrings("/ip4/127.0.0.1/tcp/0"),
+		Fx(fx.Replace([]config.TptC{tpt})),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	// NoTransports leaves cfg.Transports empty, so a TCP listener can only
+	// exist if the replaced transport list reached the swarm.
+	if len(h.Network().ListenAddresses()) == 0 {
+		t.Fatal("expected the fx.Replace'd TCP transport to be listening")
+	}
+}
+
 func makeRandomHost(t *testing.T, port int) (host.Host, error) {
 	ctx := context.Background()
 	priv, _, err := crypto.GenerateKeyPair(crypto.RSA, 2048)